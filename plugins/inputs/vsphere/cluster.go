@@ -0,0 +1,112 @@
+package vsphere
+
+import (
+	"context"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"github.com/influxdata/telegraf"
+)
+
+// gatherClusterMetrics emits the aggregated capacity/utilization view for a
+// cluster that cannot be computed from per-host metrics alone: its
+// ClusterComputeResourceSummary plus the underlying UsageSummary.
+func (v *VSphere) gatherClusterMetrics(acc telegraf.Accumulator, ctx context.Context, client *govmomi.Client, clusters []*object.ClusterComputeResource, dcName string) error {
+	var refs []types.ManagedObjectReference
+	for _, obj := range clusters {
+		refs = append(refs, obj.Reference())
+	}
+
+	collector := property.DefaultCollector(client.Client)
+	var results []mo.ClusterComputeResource
+	err := collector.Retrieve(ctx, refs, []string{"name", "summary"}, &results)
+	if err != nil {
+		return err
+	}
+
+	for _, cluster := range results {
+		summary, ok := cluster.Summary.(*types.ClusterComputeResourceSummary)
+		if !ok {
+			continue
+		}
+
+		records := make(map[string]interface{})
+		tags := make(map[string]string)
+
+		tags["name"] = cluster.Name
+		tags["datacenter"] = dcName
+
+		records["health_status"] = string(summary.OverallStatus)
+		records["num_hosts"] = summary.NumHosts
+		records["num_effective_hosts"] = summary.NumEffectiveHosts
+
+		records["effective_cpu"] = summary.EffectiveCpu
+		records["effective_memory"] = summary.EffectiveMemory
+		records["total_cpu"] = summary.TotalCpu
+		records["total_memory"] = summary.TotalMemory
+
+		if usage := summary.UsageSummary; usage != nil {
+			records["cpu_demand"] = usage.CpuDemandMHz
+			records["cpu_capacity"] = usage.CpuCapacityMHz
+			records["cpu_reservation"] = usage.CpuReservationMHz
+			records["mem_demand"] = usage.MemDemandMB
+			records["mem_capacity"] = usage.MemCapacityMB
+			records["mem_reservation"] = usage.MemReservationMB
+		}
+
+		acc.AddFields(v.measurement("cluster"), records, tags)
+	}
+
+	return nil
+}
+
+// gatherResourcePoolMetrics emits the reservation vs. usage view for a
+// resource pool, sourced from its RuntimeInfo and QuickStats.
+func (v *VSphere) gatherResourcePoolMetrics(acc telegraf.Accumulator, ctx context.Context, client *govmomi.Client, pools []*object.ResourcePool, dcName string) error {
+	var refs []types.ManagedObjectReference
+	for _, obj := range pools {
+		refs = append(refs, obj.Reference())
+	}
+
+	collector := property.DefaultCollector(client.Client)
+	var results []mo.ResourcePool
+	err := collector.Retrieve(ctx, refs, []string{"name", "runtime", "summary"}, &results)
+	if err != nil {
+		return err
+	}
+
+	for _, pool := range results {
+		records := make(map[string]interface{})
+		tags := make(map[string]string)
+
+		tags["name"] = pool.Name
+		tags["datacenter"] = dcName
+
+		records["cpu_reservation_used"] = pool.Runtime.Cpu.ReservationUsed
+		records["cpu_unreserved"] = pool.Runtime.Cpu.UnreservedForPool
+		records["cpu_max_usage"] = pool.Runtime.Cpu.MaxUsage
+		records["cpu_overall_usage"] = pool.Runtime.Cpu.OverallUsage
+
+		records["memory_reservation_used"] = pool.Runtime.Memory.ReservationUsed
+		records["memory_unreserved"] = pool.Runtime.Memory.UnreservedForPool
+		records["memory_max_usage"] = pool.Runtime.Memory.MaxUsage
+		records["memory_overall_usage"] = pool.Runtime.Memory.OverallUsage
+
+		if summary := pool.Summary.GetResourcePoolSummary(); summary != nil {
+			if quickStats := summary.QuickStats; quickStats != nil {
+				records["cpu_demand"] = quickStats.OverallCpuDemand
+				records["cpu_usage"] = quickStats.OverallCpuUsage
+				records["memory_usage"] = quickStats.HostMemoryUsage
+				records["memory_guest_active"] = quickStats.GuestMemoryUsage
+			}
+		}
+
+		acc.AddFields(v.measurement("resource_pool"), records, tags)
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+// Package govclient provides a long-lived, self-healing govmomi client so
+// the vsphere plugin doesn't need to log in to vCenter/ESXi from scratch on
+// every Gather cycle.
+package govclient
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/vim25/soap"
+)
+
+// DefaultTimeout bounds every RPC issued through a Client when no explicit
+// Timeout is configured.
+const DefaultTimeout = 60 * time.Second
+
+// keepAliveIdle is how long the connection may sit idle before the
+// keepalive handler pings the session to keep it from timing out.
+const keepAliveIdle = 10 * time.Minute
+
+// Client owns a single long-lived *govmomi.Client, keeping its session alive
+// between calls and transparently re-logging in if vCenter expires or drops
+// it. It is meant to be kept across Gather cycles: a govmomi session is
+// reused from one interval to the next, and a new one is only established
+// when the cached one has expired or gone stale.
+type Client struct {
+	Server   string
+	Username string
+	Password string
+	Insecure bool
+	Timeout  time.Duration
+
+	conn *govmomi.Client
+}
+
+// Get returns the cached govmomi client, logging in (or re-logging in, if
+// the cached session has expired or gone stale) as needed. Every RPC made
+// while establishing the connection is bounded by Timeout (DefaultTimeout if
+// unset).
+func (c *Client) Get(ctx context.Context) (*govmomi.Client, error) {
+	rpcCtx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+
+	if c.conn != nil {
+		active, err := c.conn.SessionManager.SessionIsActive(rpcCtx)
+		if err == nil && active {
+			return c.conn, nil
+		}
+	}
+
+	return c.login(rpcCtx)
+}
+
+// Close logs the cached session out, if one is established.
+func (c *Client) Close(ctx context.Context) error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Logout(ctx)
+	c.conn = nil
+	return err
+}
+
+func (c *Client) login(ctx context.Context) (*govmomi.Client, error) {
+	u, err := url.Parse(fmt.Sprintf("https://%s:%s@%s/sdk", c.Username, c.Password, c.Server))
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := govmomi.NewClient(ctx, u, c.Insecure)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.Client.RoundTripper = session.KeepAliveHandler(conn.Client.RoundTripper, keepAliveIdle, func(rt soap.RoundTripper) error {
+		// This fires long after the ctx passed to login/Get has been
+		// canceled by its deferred cancel(), so it needs a context of its
+		// own rather than closing over that one.
+		keepAliveCtx, cancel := context.WithTimeout(context.Background(), c.timeout())
+		defer cancel()
+
+		active, err := conn.SessionManager.SessionIsActive(keepAliveCtx)
+		if err != nil || !active {
+			return conn.SessionManager.Login(keepAliveCtx, url.UserPassword(c.Username, c.Password))
+		}
+		return nil
+	})
+
+	c.conn = conn
+	return conn, nil
+}
+
+// timeout returns the configured Timeout, or DefaultTimeout if unset.
+func (c *Client) timeout() time.Duration {
+	if c.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return c.Timeout
+}
+
+// EffectiveTimeout returns the bound Get/login/keepalive calls are made
+// with, so callers can derive a context of the same length to cover the
+// RPCs they go on to make against the returned *govmomi.Client.
+func (c *Client) EffectiveTimeout() time.Duration {
+	return c.timeout()
+}
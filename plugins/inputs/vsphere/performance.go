@@ -0,0 +1,234 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/performance"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"github.com/influxdata/telegraf"
+)
+
+// defaultPerfInterval is the real time counter interval vCenter/ESXi keeps
+// for hosts and VMs when no historical rollup interval is configured.
+const defaultPerfInterval = 20
+
+// perfCounterPresets expands a convenience name into the full vSphere
+// counter names it stands for, so a config can say `perf_counters = ["cpu"]`
+// instead of spelling out every counter.
+var perfCounterPresets = map[string][]string{
+	"cpu": {
+		"cpu.usage.average",
+	},
+	"mem": {
+		"mem.active.average",
+	},
+	"disk": {
+		"disk.read.average",
+		"disk.write.average",
+	},
+	"net": {
+		"net.received.average",
+		"net.transmitted.average",
+	},
+	"virtualdisk": {
+		"virtualDisk.totalReadLatency.average",
+	},
+}
+
+// ensurePerfCounterCache resolves the vSphere counter-name -> PerfCounterInfo
+// map on first use and keeps it on the plugin instance for its whole life
+// (it is not reset when the session is re-established), so counter IDs
+// don't need to be re-queried from PerformanceManager on every Gather.
+func (v *VSphere) ensurePerfCounterCache(ctx context.Context, client *govmomi.Client) error {
+	v.perfCounterCacheMu.Lock()
+	defer v.perfCounterCacheMu.Unlock()
+
+	if v.perfCounterCache != nil {
+		return nil
+	}
+
+	pm := performance.NewManager(client.Client)
+	counters, err := pm.CounterInfoByName(ctx)
+	if err != nil {
+		return err
+	}
+	v.perfCounterCache = counters
+
+	if v.DiscoverPerfCounters {
+		names := make([]string, 0, len(counters))
+		for name := range counters {
+			names = append(names, name)
+		}
+		log.Printf("I! [inputs.vsphere] %d performance counters available: %s", len(names), strings.Join(names, ", "))
+	}
+
+	return nil
+}
+
+// resolvedCounterNames expands any presets in PerfCounters (the "cpu", "mem",
+// "disk" and "net" presets if it is empty), drops names missing from the
+// cached counter metadata, and deduplicates the result.
+func (v *VSphere) resolvedCounterNames() []string {
+	requested := v.PerfCounters
+	if len(requested) == 0 {
+		requested = []string{"cpu", "mem", "disk", "net"}
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, name := range requested {
+		expansion, isPreset := perfCounterPresets[name]
+		if !isPreset {
+			expansion = []string{name}
+		}
+		for _, n := range expansion {
+			if _, ok := v.perfCounterCache[n]; !ok {
+				continue
+			}
+			if seen[n] {
+				continue
+			}
+			seen[n] = true
+			names = append(names, n)
+		}
+	}
+
+	return names
+}
+
+// perfInterval returns the configured sampling interval, defaulting to the
+// real time interval vCenter/ESXi always maintains.
+func (v *VSphere) perfInterval() int32 {
+	if v.PerfInterval <= 0 {
+		return defaultPerfInterval
+	}
+	return v.PerfInterval
+}
+
+// translateCounterValue converts a raw PerfMetricIntSeries value into the
+// unit its counter is actually expressed in: percentages are reported in
+// hundredths of a percent, and several counters report kilobytes.
+func translateCounterValue(counter types.PerfCounterInfo, raw int64) float64 {
+	switch counter.UnitInfo.GetElementDescription().Key {
+	case "percent":
+		return float64(raw) / 100.0
+	case "kiloBytes", "kiloBytesPerSecond":
+		return float64(raw) * 1024
+	default:
+		return float64(raw)
+	}
+}
+
+// gatherPerfMetrics queries real time performance counters for a set of
+// entities and emits one metric per (entity, counter, instance).
+func (v *VSphere) gatherPerfMetrics(acc telegraf.Accumulator, ctx context.Context, client *govmomi.Client, entities map[types.ManagedObjectReference]map[string]string, measurement string) error {
+	if len(entities) == 0 {
+		return nil
+	}
+
+	names := v.resolvedCounterNames()
+	if len(names) == 0 {
+		return nil
+	}
+
+	counterIDs := make([]int32, 0, len(names))
+	infoByID := make(map[int32]types.PerfCounterInfo, len(names))
+	for _, name := range names {
+		info := v.perfCounterCache[name]
+		counterIDs = append(counterIDs, info.Key)
+		infoByID[info.Key] = info
+	}
+
+	pm := performance.NewManager(client.Client)
+	interval := v.perfInterval()
+
+	var specs []types.PerfQuerySpec
+	for ref := range entities {
+		specs = append(specs, types.PerfQuerySpec{
+			Entity:     ref,
+			MaxSample:  1,
+			IntervalId: interval,
+			MetricId:   perfMetricIDs(counterIDs),
+		})
+	}
+
+	sample, err := pm.Query(ctx, specs)
+	if err != nil {
+		return err
+	}
+
+	results, err := pm.ToMetricSeries(ctx, sample)
+	if err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		baseTags := entities[result.Entity.Reference()]
+
+		for _, series := range result.Value {
+			info, ok := infoByID[series.Info.Key]
+			if !ok || len(series.Value) == 0 {
+				continue
+			}
+
+			tags := make(map[string]string, len(baseTags)+1)
+			for k, v := range baseTags {
+				tags[k] = v
+			}
+			if series.Instance != "" {
+				tags["instance"] = series.Instance
+			}
+
+			fields := map[string]interface{}{
+				counterFieldName(info): translateCounterValue(info, series.Value[len(series.Value)-1]),
+			}
+
+			acc.AddFields(measurement, fields, tags)
+		}
+	}
+
+	return nil
+}
+
+// counterFieldName turns a vSphere counter name like "cpu.usage.average"
+// into a flat field name, e.g. "cpu_usage_average".
+func counterFieldName(info types.PerfCounterInfo) string {
+	name := fmt.Sprintf("%s.%s.%s", info.GroupInfo.GetElementDescription().Key, info.NameInfo.GetElementDescription().Key, info.RollupType)
+	return strings.Replace(name, ".", "_", -1)
+}
+
+func perfMetricIDs(counterIDs []int32) []types.PerfMetricId {
+	ids := make([]types.PerfMetricId, 0, len(counterIDs))
+	for _, id := range counterIDs {
+		ids = append(ids, types.PerfMetricId{CounterId: id, Instance: "*"})
+	}
+	return ids
+}
+
+func (v *VSphere) gatherHostPerfMetrics(acc telegraf.Accumulator, ctx context.Context, client *govmomi.Client, hosts []*object.HostSystem, dcName string) error {
+	entities := make(map[types.ManagedObjectReference]map[string]string, len(hosts))
+	for _, host := range hosts {
+		entities[host.Reference()] = map[string]string{
+			"name":       host.Name(),
+			"datacenter": dcName,
+		}
+	}
+	return v.gatherPerfMetrics(acc, ctx, client, entities, v.measurement("host_perf"))
+}
+
+func (v *VSphere) gatherVMPerfMetrics(acc telegraf.Accumulator, ctx context.Context, client *govmomi.Client, vms []*object.VirtualMachine, dcName string) error {
+	entities := make(map[types.ManagedObjectReference]map[string]string, len(vms))
+	for _, vm := range vms {
+		entities[vm.Reference()] = map[string]string{
+			"name":       vm.Name(),
+			"datacenter": dcName,
+		}
+	}
+	return v.gatherPerfMetrics(acc, ctx, client, entities, v.measurement("virtual_machine_perf"))
+}
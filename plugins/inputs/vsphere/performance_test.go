@@ -0,0 +1,105 @@
+package vsphere
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestTranslateCounterValue(t *testing.T) {
+	tests := []struct {
+		name string
+		unit string
+		raw  int64
+		want float64
+	}{
+		{"percent is hundredths", "percent", 1234, 1234.0 / 100.0},
+		{"kiloBytes to bytes", "kiloBytes", 2, 2048},
+		{"kiloBytesPerSecond to bytes", "kiloBytesPerSecond", 3, 3072},
+		{"unknown unit passes through", "number", 42, 42},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := types.PerfCounterInfo{
+				UnitInfo: &types.ElementDescription{Key: tt.unit},
+			}
+			if got := translateCounterValue(counter, tt.raw); got != tt.want {
+				t.Errorf("translateCounterValue(%s, %d) = %v, want %v", tt.unit, tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCounterFieldName(t *testing.T) {
+	counter := types.PerfCounterInfo{
+		GroupInfo:  &types.ElementDescription{Key: "cpu"},
+		NameInfo:   &types.ElementDescription{Key: "usage"},
+		RollupType: types.PerfSummaryTypeAverage,
+	}
+
+	want := "cpu_usage_average"
+	if got := counterFieldName(counter); got != want {
+		t.Errorf("counterFieldName() = %q, want %q", got, want)
+	}
+}
+
+func TestResolvedCounterNames(t *testing.T) {
+	available := map[string]types.PerfCounterInfo{
+		"cpu.usage.average":     {},
+		"mem.active.average":    {},
+		"disk.read.average":     {},
+		"disk.write.average":    {},
+		"net.received.average":  {},
+		"some.unrequested.name": {},
+	}
+
+	tests := []struct {
+		name     string
+		counters []string
+		want     []string
+	}{
+		{
+			"default presets when unset",
+			nil,
+			[]string{"cpu.usage.average", "mem.active.average", "disk.read.average", "disk.write.average", "net.received.average"},
+		},
+		{
+			"explicit preset expands",
+			[]string{"disk"},
+			[]string{"disk.read.average", "disk.write.average"},
+		},
+		{
+			"explicit counter name passes through",
+			[]string{"mem.active.average"},
+			[]string{"mem.active.average"},
+		},
+		{
+			"preset and explicit counter name are deduplicated",
+			[]string{"cpu", "cpu.usage.average"},
+			[]string{"cpu.usage.average"},
+		},
+		{
+			"counters missing from the cache are dropped",
+			[]string{"cpu.usage.average", "gpu.usage.average"},
+			[]string{"cpu.usage.average"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &VSphere{PerfCounters: tt.counters, perfCounterCache: available}
+
+			got := v.resolvedCounterNames()
+			sort.Strings(got)
+			want := append([]string(nil), tt.want...)
+			sort.Strings(want)
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("resolvedCounterNames() = %v, want %v", got, want)
+			}
+		})
+	}
+}
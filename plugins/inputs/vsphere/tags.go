@@ -0,0 +1,225 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vapi/rest"
+	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// tagInfo is the category/name pair a vSphere tag resolves to.
+type tagInfo struct {
+	name     string
+	category string
+}
+
+// metadataSource lazily resolves and caches the two out-of-band sources of
+// metadata vCenter can attach to an entity: Custom Fields and vSphere Tags.
+// Custom Field names are looked up through CustomFieldsManager over the
+// existing SOAP session; Tags need a session of their own on the vAPI/REST
+// endpoint. Each is only set up the first time a whitelist actually asks
+// for it.
+type metadataSource struct {
+	mu sync.Mutex
+
+	customFieldNames map[int32]string
+
+	restClient  *rest.Client
+	tagsManager *tags.Manager
+	tagCache    map[string]tagInfo
+}
+
+// customAttributeTags resolves the whitelisted Custom Field values set on an
+// entity into tag-name/value pairs, keyed by field name.
+func (m *metadataSource) customAttributeTags(ctx context.Context, client *govmomi.Client, customValue []types.BaseCustomFieldValue, whitelist []string) (map[string]string, error) {
+	if len(whitelist) == 0 || len(customValue) == 0 {
+		return nil, nil
+	}
+
+	if err := m.ensureCustomFieldNames(ctx, client); err != nil {
+		return nil, err
+	}
+
+	allowed := make(map[string]bool, len(whitelist))
+	for _, key := range whitelist {
+		allowed[key] = true
+	}
+
+	result := make(map[string]string)
+	for _, baseValue := range customValue {
+		value, ok := baseValue.(*types.CustomFieldStringValue)
+		if !ok {
+			continue
+		}
+		name, ok := m.customFieldNames[value.Key]
+		if !ok || !allowed[name] {
+			continue
+		}
+		result[name] = value.Value
+	}
+
+	return result, nil
+}
+
+// ensureCustomFieldNames resolves the Custom Field key -> name mapping via
+// CustomFieldsManager on first use and keeps it for the life of the plugin
+// instance.
+func (m *metadataSource) ensureCustomFieldNames(ctx context.Context, client *govmomi.Client) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.customFieldNames != nil {
+		return nil
+	}
+
+	cfm, err := object.GetCustomFieldsManager(client.Client)
+	if err != nil {
+		return err
+	}
+	fields, err := cfm.Field(ctx)
+	if err != nil {
+		return err
+	}
+
+	names := make(map[int32]string, len(fields))
+	for _, field := range fields {
+		names[field.Key] = field.Name
+	}
+	m.customFieldNames = names
+
+	return nil
+}
+
+// vsphereTags resolves the whitelisted tag categories attached to an entity
+// into tag-name/value pairs, keyed by category name.
+func (m *metadataSource) vsphereTags(ctx context.Context, client *govmomi.Client, server, username, password string, insecure bool, ref types.ManagedObjectReference, whitelist []string) (map[string]string, error) {
+	if len(whitelist) == 0 {
+		return nil, nil
+	}
+
+	if err := m.ensureTagsManager(ctx, client, server, username, password, insecure); err != nil {
+		return nil, err
+	}
+
+	allowed := make(map[string]bool, len(whitelist))
+	for _, category := range whitelist {
+		allowed[category] = true
+	}
+
+	attached, err := m.tagsManager.ListAttachedTags(ctx, staticReference{ref})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string)
+	for _, tagID := range attached {
+		info, err := m.resolveTag(ctx, tagID)
+		if err != nil {
+			continue
+		}
+		if !allowed[info.category] {
+			continue
+		}
+		result[info.category] = info.name
+	}
+
+	return result, nil
+}
+
+// ensureTagsManager logs into the vAPI/REST endpoint and builds a tags
+// Manager on first use, and keeps both for the life of the plugin instance.
+func (m *metadataSource) ensureTagsManager(ctx context.Context, client *govmomi.Client, server, username, password string, insecure bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.tagsManager != nil {
+		return nil
+	}
+
+	restClient := rest.NewClient(client.Client)
+	if err := restClient.Login(ctx, url.UserPassword(username, password)); err != nil {
+		return fmt.Errorf("Cannot log in to vAPI/REST endpoint on '%s': %s", server, err)
+	}
+
+	m.restClient = restClient
+	m.tagsManager = tags.NewManager(restClient)
+	m.tagCache = make(map[string]tagInfo)
+
+	return nil
+}
+
+// resolveTag resolves and caches a tag ID into its name/category pair.
+// The cache is guarded by m.mu since this is called concurrently from the
+// per-name-pattern goroutines in Gather.
+func (m *metadataSource) resolveTag(ctx context.Context, tagID string) (tagInfo, error) {
+	m.mu.Lock()
+	info, ok := m.tagCache[tagID]
+	m.mu.Unlock()
+	if ok {
+		return info, nil
+	}
+
+	tag, err := m.tagsManager.GetTag(ctx, tagID)
+	if err != nil {
+		return tagInfo{}, err
+	}
+	category, err := m.tagsManager.GetCategory(ctx, tag.CategoryID)
+	if err != nil {
+		return tagInfo{}, err
+	}
+
+	info = tagInfo{name: tag.Name, category: category.Name}
+
+	m.mu.Lock()
+	m.tagCache[tagID] = info
+	m.mu.Unlock()
+
+	return info, nil
+}
+
+// staticReference adapts a bare types.ManagedObjectReference to the
+// mo.Reference interface the tags Manager expects.
+type staticReference struct {
+	ref types.ManagedObjectReference
+}
+
+func (s staticReference) Reference() types.ManagedObjectReference {
+	return s.ref
+}
+
+// entityTags merges the whitelisted custom-attribute and vSphere-tag
+// metadata for an entity into a single tag map. It uses the whitelists as
+// given; callers pick the per-metric-type or global list with
+// overrideOrGlobal.
+func (v *VSphere) entityTags(ctx context.Context, client *govmomi.Client, ref types.ManagedObjectReference, customValue []types.BaseCustomFieldValue, customWhitelist, tagWhitelist []string) map[string]string {
+	result := make(map[string]string)
+
+	if attrs, err := v.metadata.customAttributeTags(ctx, client, customValue, customWhitelist); err == nil {
+		for k, val := range attrs {
+			result[k] = val
+		}
+	}
+
+	if tagValues, err := v.metadata.vsphereTags(ctx, client, v.Server, v.Username, v.Password, v.Insecure, ref, tagWhitelist); err == nil {
+		for k, val := range tagValues {
+			result[k] = val
+		}
+	}
+
+	return result
+}
+
+// overrideOrGlobal returns the per-metric-type whitelist when set, falling
+// back to the global whitelist otherwise.
+func overrideOrGlobal(override, global []string) []string {
+	if len(override) > 0 {
+		return override
+	}
+	return global
+}
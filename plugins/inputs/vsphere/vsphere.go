@@ -3,28 +3,81 @@ package vsphere
 import (
 	"context"
 	"fmt"
-	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/vmware/govmomi"
 	"github.com/vmware/govmomi/find"
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/view"
 	"github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/types"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/plugins/inputs"
-	"sync"
+	"github.com/influxdata/telegraf/plugins/inputs/vsphere/internal/govclient"
 )
 
 type VSphere struct {
-	Server          string `json:"server"`
-	Username        string `json:"username"`
-	Password        string `json:"password"`
-	Insecure        bool   `json:"insecure"`
-	Hosts           []string   `json:"hosts"`
-	Datastores      []string   `json:"datastores"`
-	VirtualMachines []string   `json:"virtual_machines"`
+	Server          string   `json:"server"`
+	Username        string   `json:"username"`
+	Password        string   `json:"password"`
+	Insecure        bool     `json:"insecure"`
+	Timeout         int64    `json:"timeout"`
+	Datacenters     []string `json:"datacenters"`
+	Hosts           []string `json:"hosts"`
+	Datastores      []string `json:"datastores"`
+	VirtualMachines []string `json:"virtual_machines"`
+	Clusters        []string `json:"clusters"`
+	ResourcePools   []string `json:"resource_pools"`
+
+	PerfEnabled          bool     `json:"perf_enabled"`
+	PerfCounters         []string `json:"perf_counters"`
+	PerfInterval         int32    `json:"perf_interval"`
+	DiscoverPerfCounters bool     `json:"discover_perf_counters"`
+
+	// CustomAttributes and TagCategories whitelist which vCenter Custom
+	// Fields and vSphere Tag categories are propagated as metric tags. The
+	// Host/VM/Datastore variants override the corresponding global list for
+	// that metric type only.
+	CustomAttributes          []string `json:"custom_attributes"`
+	HostCustomAttributes      []string `json:"host_custom_attributes"`
+	VMCustomAttributes        []string `json:"vm_custom_attributes"`
+	DatastoreCustomAttributes []string `json:"datastore_custom_attributes"`
+
+	TagCategories          []string `json:"tag_categories"`
+	HostTagCategories      []string `json:"host_tag_categories"`
+	VMTagCategories        []string `json:"vm_tag_categories"`
+	DatastoreTagCategories []string `json:"datastore_tag_categories"`
+
+	// MeasurementPrefix lets several vCenters writing to the same InfluxDB
+	// be told apart (e.g. "vsphere_prod_host" vs. "vsphere_dev_host").
+	MeasurementPrefix string `json:"measurement_prefix"`
+
+	// RemoveHostDomainName strips a guest's FQDN down to its short name
+	// before it's used as the "hostname" tag, using Domain as the suffix to
+	// strip if set, or everything after the first dot otherwise.
+	RemoveHostDomainName bool   `json:"remove_host_domain_name"`
+	Domain               string `json:"domain"`
+
+	perfCounterCache   map[string]types.PerfCounterInfo
+	perfCounterCacheMu sync.Mutex
+
+	clusterNameCache   map[types.ManagedObjectReference]string
+	clusterNameCacheMu sync.Mutex
+
+	client   govclient.Client
+	metadata metadataSource
+}
+
+// datacenter pairs a resolved *object.Datacenter with the name it was
+// discovered under, so gatherers can tag metrics without a second lookup.
+type datacenter struct {
+	name string
+	obj  *object.Datacenter
 }
 
 var sampleConfig = `
@@ -41,6 +94,16 @@ var sampleConfig = `
   ## Do not validate server's TLS certificate
   # insecure =  true
 
+  ## Timeout, in seconds, for each gather: all vCenter/ESXi RPCs of one
+  ## gather, including any login, share a single deadline of this length.
+  ## Keepalive pings and re-logins between gathers get their own.
+  # timeout = 60
+
+  ## Datacenter name patterns. The inventory tree is walked recursively
+  ## from the root folder, so datacenters nested under sub-folders are
+  ## discovered as well.
+  # datacenters = ["*"]
+
   ## Host name patterns
   # hosts = ["*"]
 
@@ -49,6 +112,56 @@ var sampleConfig = `
 
   ## Virtual machine name patterns
   # virtual_machines = ["*"]
+
+  ## Cluster name patterns
+  # clusters = ["*"]
+
+  ## Resource pool name patterns
+  # resource_pools = ["*"]
+
+  ## Collect real time-series performance counters via PerformanceManager,
+  ## in addition to the QuickStats snapshot above.
+  # perf_enabled = false
+
+  ## Performance counters to collect, e.g. "cpu.usage.average". Presets
+  ## "cpu", "mem", "disk" and "net" expand to a curated set of counters.
+  # perf_counters = ["cpu", "mem", "disk", "net"]
+
+  ## Real time performance interval in seconds. Must match an interval
+  ## vCenter/ESXi actually maintains (20 for real time, or 300/1800/... for
+  ## historical rollups).
+  # perf_interval = 20
+
+  ## Log every performance counter vCenter/ESXi knows about on startup.
+  ## Useful for discovering names to put in perf_counters.
+  # discover_perf_counters = false
+
+  ## vCenter Custom Field keys to propagate as tags, applied to every
+  ## metric type unless a host_/vm_/datastore_ override below is set.
+  # custom_attributes = ["owner", "env"]
+  # host_custom_attributes = []
+  # vm_custom_attributes = []
+  # datastore_custom_attributes = []
+
+  ## vSphere Tag categories to propagate as tags, applied to every metric
+  ## type unless a host_/vm_/datastore_ override below is set. Requires
+  ## the vAPI/REST endpoint, logged into with the same credentials above.
+  # tag_categories = ["application", "tier"]
+  # host_tag_categories = []
+  # vm_tag_categories = []
+  # datastore_tag_categories = []
+
+  ## Prefix prepended to every measurement name this plugin emits (host,
+  ## datastore, virtual_machine, cluster, resource_pool, host_perf,
+  ## virtual_machine_perf), so multiple vCenters writing to the same
+  ## InfluxDB can be told apart (e.g. "vsphere_prod_host", "vsphere_dev_host").
+  # measurement_prefix = ""
+
+  ## Strip guest hostnames down to their short name before tagging metrics
+  ## with them. If domain is set, only that suffix is stripped; otherwise
+  ## everything after the first dot is removed.
+  # remove_host_domain_name = false
+  # domain = ""
 `
 
 func (v *VSphere) Description() string {
@@ -60,110 +173,324 @@ func (v *VSphere) SampleConfig() string {
 }
 
 func (v *VSphere) Gather(acc telegraf.Accumulator) error {
-	ctx, cancel := context.WithCancel(context.Background())
+	v.client.Server = v.Server
+	v.client.Username = v.Username
+	v.client.Password = v.Password
+	v.client.Insecure = v.Insecure
+	v.client.Timeout = time.Duration(v.Timeout) * time.Second
+
+	// Every RPC made over the course of this Gather - login, listing,
+	// property Retrieve, performance queries, REST tag lookups - shares one
+	// deadline of the session helper's timeout, counted from here, so a
+	// wedged connection or a slow vCenter can't hang Gather (and wg.Wait())
+	// indefinitely. The timeout is not applied to each RPC separately.
+	ctx, cancel := context.WithTimeout(context.Background(), v.client.EffectiveTimeout())
 	defer cancel()
 
-	// Parse URL from string
-	u, err := url.Parse(fmt.Sprintf("https://%s:%s@%s/sdk", v.Username, v.Password, v.Server))
+	// Connect and log in to ESX or vCenter, reusing the cached session if
+	// it's still alive.
+	client, err := v.client.Get(ctx)
 	if err != nil {
 		return err
 	}
 
-	// Connect and log in to ESX or vCenter
-	client, err := govmomi.NewClient(ctx, u, v.Insecure)
+	datacenters, err := v.discoverDatacenters(ctx, client)
 	if err != nil {
-		return err
+		return fmt.Errorf("Cannot discover datacenters: %s", err)
 	}
-	finder := find.NewFinder(client.Client, true)
 
-	// Find one and only datacenter
-	dc, err := finder.DefaultDatacenter(ctx)
-	if err != nil {
-		return err
+	perfAvailable := v.PerfEnabled
+	if perfAvailable {
+		if err := v.ensurePerfCounterCache(ctx, client); err != nil {
+			acc.AddError(fmt.Errorf("Cannot load performance counter metadata: %s", err))
+			// Leave v.PerfEnabled (and perfCounterCache) untouched so the
+			// next Gather retries instead of disabling the feature forever
+			// over one transient RPC failure.
+			perfAvailable = false
+		}
 	}
-	finder.SetDatacenter(dc)
 
 	var wg sync.WaitGroup
 
-	for _, name := range v.Hosts {
-		wg.Add(1)
-		go func(name string) {
-			defer wg.Done()
+	for _, dc := range datacenters {
+		finder := find.NewFinder(client.Client, true)
+		finder.SetDatacenter(dc.obj)
+
+		for _, name := range v.Hosts {
+			wg.Add(1)
+			go func(dc datacenter, name string) {
+				defer wg.Done()
+
+				hosts, err := finder.HostSystemList(ctx, name)
+				if err != nil {
+					acc.AddError(fmt.Errorf("Cannot read host list for '%s' in datacenter '%s': %s", name, dc.name, err))
+					return
+				}
+
+				err = v.gatherHostMetrics(acc, ctx, client, hosts, dc.name)
+				if err != nil {
+					acc.AddError(fmt.Errorf("Cannot read host properties for '%s' in datacenter '%s': %s", name, dc.name, err))
+					return
+				}
+
+				if perfAvailable {
+					if err := v.gatherHostPerfMetrics(acc, ctx, client, hosts, dc.name); err != nil {
+						acc.AddError(fmt.Errorf("Cannot read host performance counters for '%s' in datacenter '%s': %s", name, dc.name, err))
+					}
+				}
+			}(dc, name)
+		}
+
+		for _, name := range v.Datastores {
+			wg.Add(1)
+			go func(dc datacenter, name string) {
+				defer wg.Done()
+
+				datastores, err := finder.DatastoreList(ctx, name)
+				if err != nil {
+					acc.AddError(fmt.Errorf("Cannot read datastore list for '%s' in datacenter '%s': %s", name, dc.name, err))
+					return
+				}
+				err = v.gatherDatastoreMetrics(acc, ctx, client, datastores, dc.name)
+				if err != nil {
+					acc.AddError(fmt.Errorf("Cannot read datastore properties for '%s' in datacenter '%s': %s", name, dc.name, err))
+					return
+				}
+			}(dc, name)
+		}
+
+		for _, name := range v.VirtualMachines {
+			wg.Add(1)
+			go func(dc datacenter, name string) {
+				defer wg.Done()
+
+				vms, err := finder.VirtualMachineList(ctx, name)
+				if err != nil {
+					acc.AddError(fmt.Errorf("Cannot read vm list for '%s' in datacenter '%s': %s", name, dc.name, err))
+					return
+				}
+				err = v.gatherVMMetrics(acc, ctx, client, vms, dc.name)
+				if err != nil {
+					acc.AddError(fmt.Errorf("Cannot read vm properties for '%s' in datacenter '%s': %s", name, dc.name, err))
+					return
+				}
+
+				if perfAvailable {
+					if err := v.gatherVMPerfMetrics(acc, ctx, client, vms, dc.name); err != nil {
+						acc.AddError(fmt.Errorf("Cannot read vm performance counters for '%s' in datacenter '%s': %s", name, dc.name, err))
+					}
+				}
+			}(dc, name)
+		}
+
+		for _, name := range v.Clusters {
+			wg.Add(1)
+			go func(dc datacenter, name string) {
+				defer wg.Done()
+
+				clusters, err := finder.ClusterComputeResourceList(ctx, name)
+				if err != nil {
+					acc.AddError(fmt.Errorf("Cannot read cluster list for '%s' in datacenter '%s': %s", name, dc.name, err))
+					return
+				}
+				err = v.gatherClusterMetrics(acc, ctx, client, clusters, dc.name)
+				if err != nil {
+					acc.AddError(fmt.Errorf("Cannot read cluster properties for '%s' in datacenter '%s': %s", name, dc.name, err))
+					return
+				}
+			}(dc, name)
+		}
+
+		for _, name := range v.ResourcePools {
+			wg.Add(1)
+			go func(dc datacenter, name string) {
+				defer wg.Done()
+
+				pools, err := finder.ResourcePoolList(ctx, name)
+				if err != nil {
+					acc.AddError(fmt.Errorf("Cannot read resource pool list for '%s' in datacenter '%s': %s", name, dc.name, err))
+					return
+				}
+				err = v.gatherResourcePoolMetrics(acc, ctx, client, pools, dc.name)
+				if err != nil {
+					acc.AddError(fmt.Errorf("Cannot read resource pool properties for '%s' in datacenter '%s': %s", name, dc.name, err))
+					return
+				}
+			}(dc, name)
+		}
+	}
 
-			hosts, err := finder.HostSystemList(ctx, name)
-			if err != nil {
-				acc.AddError(fmt.Errorf("Cannot read host list for '%s': %s", name, err))
-				return
-			}
+	wg.Wait()
+	return nil
+}
 
+// discoverDatacenters walks the entire inventory tree from the root folder,
+// descending into nested folders, and returns every datacenter whose name
+// matches one of the configured patterns. This is needed because
+// find.Finder.DefaultDatacenter fails (or picks the wrong datacenter) when
+// datacenters live under sub-folders of the root.
+func (v *VSphere) discoverDatacenters(ctx context.Context, client *govmomi.Client) ([]datacenter, error) {
+	m := view.NewManager(client.Client)
 
-			err = v.gatherHostMetrics(acc, ctx, client, hosts)
-			if err != nil {
-				acc.AddError(fmt.Errorf("Cannot read host properties for '%s': %s", name, err))
-				return
-			}
-		}(name)
+	cv, err := m.CreateContainerView(ctx, client.ServiceContent.RootFolder, []string{"Datacenter"}, true)
+	if err != nil {
+		return nil, err
 	}
+	defer cv.Destroy(ctx)
 
-	for _, name := range v.Datastores {
-		wg.Add(1)
-		go func(name string) {
-			defer wg.Done()
+	var mdcs []mo.Datacenter
+	if err := cv.Retrieve(ctx, []string{"Datacenter"}, []string{"name"}, &mdcs); err != nil {
+		return nil, err
+	}
 
-			datastores, err := finder.DatastoreList(ctx, name)
-			if err != nil {
-				acc.AddError(fmt.Errorf("Cannot read datastore list for '%s': %s", name, err))
-				return
-			}
-			err = v.gatherDatastoreMetrics(acc, ctx, client, datastores)
-			if err != nil {
-				acc.AddError(fmt.Errorf("Cannot read datastore properties for '%s': %s", name, err))
-				return
+	patterns := v.Datacenters
+	if len(patterns) == 0 {
+		patterns = []string{"*"}
+	}
+
+	var dcs []datacenter
+	for _, mdc := range mdcs {
+		for _, pattern := range patterns {
+			if ok, _ := filepath.Match(pattern, mdc.Name); ok {
+				dcs = append(dcs, datacenter{
+					name: mdc.Name,
+					obj:  object.NewDatacenter(client.Client, mdc.Reference()),
+				})
+				break
 			}
-		}(name)
+		}
 	}
 
-	for _, name := range v.VirtualMachines {
-		wg.Add(1)
-		go func(name string) {
-			defer wg.Done()
+	return dcs, nil
+}
+
+// ensureClusterNames resolves any of the given ClusterComputeResource refs
+// that aren't already cached, in a single batched Retrieve call, and keeps
+// them in v.clusterNameCache for the life of the plugin instance. Cluster
+// names change rarely, so this avoids a per-host RPC on every Gather.
+func (v *VSphere) ensureClusterNames(ctx context.Context, client *govmomi.Client, refs []types.ManagedObjectReference) {
+	v.clusterNameCacheMu.Lock()
+	if v.clusterNameCache == nil {
+		v.clusterNameCache = make(map[types.ManagedObjectReference]string)
+	}
+	var missing []types.ManagedObjectReference
+	for _, ref := range refs {
+		if _, ok := v.clusterNameCache[ref]; !ok {
+			missing = append(missing, ref)
+		}
+	}
+	v.clusterNameCacheMu.Unlock()
 
-			vms, err := finder.VirtualMachineList(ctx, name)
-			if err != nil {
-				acc.AddError(fmt.Errorf("Cannot read vm list for '%s': %s", name, err))
-				return
-			}
-			err = v.gatherVMMetrics(acc, ctx, client, vms)
-			if err != nil {
-				acc.AddError(fmt.Errorf("Cannot read vm properties for '%s': %s", name, err))
-				return
-			}
-		}(name)
+	if len(missing) == 0 {
+		return
 	}
 
-	wg.Wait()
-	return nil
+	collector := property.DefaultCollector(client.Client)
+	var results []mo.ManagedEntity
+	if err := collector.Retrieve(ctx, missing, []string{"name"}, &results); err != nil {
+		return
+	}
+
+	v.clusterNameCacheMu.Lock()
+	for _, me := range results {
+		v.clusterNameCache[me.Reference()] = me.Name
+	}
+	v.clusterNameCacheMu.Unlock()
+}
+
+// clusterName returns the cached display name for a ClusterComputeResource
+// ref, populated by a prior ensureClusterNames call.
+func (v *VSphere) clusterName(ref types.ManagedObjectReference) (string, bool) {
+	v.clusterNameCacheMu.Lock()
+	defer v.clusterNameCacheMu.Unlock()
+	name, ok := v.clusterNameCache[ref]
+	return name, ok
+}
+
+// folderFromInventoryPath returns the custom folder path an object lives
+// under, with the leading datacenter name and the host/vm/datastore root
+// folder and the object's own name stripped off.
+func folderFromInventoryPath(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) <= 3 {
+		return ""
+	}
+	return strings.Join(parts[2:len(parts)-1], "/")
+}
+
+// measurement prepends the configured MeasurementPrefix to a measurement
+// name, so multiple vCenters writing to the same InfluxDB don't collide.
+// Every acc.AddFields call in this package routes its measurement name
+// through this.
+func (v *VSphere) measurement(name string) string {
+	if v.MeasurementPrefix == "" {
+		return name
+	}
+	return v.MeasurementPrefix + "_" + name
+}
+
+// shortHostname strips a guest's FQDN down to its short name when
+// RemoveHostDomainName is set: Domain is trimmed as a suffix if configured,
+// otherwise everything from the first dot onward is dropped.
+func (v *VSphere) shortHostname(name string) string {
+	if !v.RemoveHostDomainName || name == "" {
+		return name
+	}
+	if v.Domain != "" {
+		return strings.TrimSuffix(name, "."+v.Domain)
+	}
+	if i := strings.Index(name, "."); i != -1 {
+		return name[:i]
+	}
+	return name
 }
 
-func (v *VSphere) gatherHostMetrics(acc telegraf.Accumulator, ctx context.Context, client *govmomi.Client, hosts []*object.HostSystem) error {
+func (v *VSphere) gatherHostMetrics(acc telegraf.Accumulator, ctx context.Context, client *govmomi.Client, hosts []*object.HostSystem, dcName string) error {
 	var refs []types.ManagedObjectReference
+	byRef := make(map[types.ManagedObjectReference]*object.HostSystem, len(hosts))
 	for _, obj := range hosts {
 		refs = append(refs, obj.Reference())
+		byRef[obj.Reference()] = obj
 	}
 
 	collector := property.DefaultCollector(client.Client)
 	var results []mo.HostSystem
-	err := collector.Retrieve(ctx, refs, []string{"name", "summary"}, &results)
+	err := collector.Retrieve(ctx, refs, []string{"name", "summary", "parent", "customValue"}, &results)
 	if err != nil {
 		return err
 	}
 
+	var clusterRefs []types.ManagedObjectReference
+	for _, host := range results {
+		if host.Parent != nil && host.Parent.Type == "ClusterComputeResource" {
+			clusterRefs = append(clusterRefs, *host.Parent)
+		}
+	}
+	v.ensureClusterNames(ctx, client, clusterRefs)
+
 	for _, host := range results {
 
 		records := make(map[string]interface{})
 		tags := make(map[string]string)
 
 		tags["name"] = host.Name
+		tags["datacenter"] = dcName
+
+		if host.Parent != nil && host.Parent.Type == "ClusterComputeResource" {
+			if clusterName, ok := v.clusterName(*host.Parent); ok {
+				tags["cluster"] = clusterName
+			}
+		}
+		if obj := byRef[host.Reference()]; obj != nil {
+			if folder := folderFromInventoryPath(obj.InventoryPath); folder != "" {
+				tags["folder"] = folder
+			}
+		}
+		for k, val := range v.entityTags(ctx, client, host.Reference(), host.CustomValue,
+			overrideOrGlobal(v.HostCustomAttributes, v.CustomAttributes),
+			overrideOrGlobal(v.HostTagCategories, v.TagCategories)) {
+			tags[k] = val
+		}
 
 		records["connection_state"] = host.Summary.Runtime.ConnectionState
 		records["health_status"] = string(host.Summary.OverallStatus)
@@ -175,23 +502,24 @@ func (v *VSphere) gatherHostMetrics(acc telegraf.Accumulator, ctx context.Contex
 		records["memory_granted"] = host.Summary.Hardware.MemorySize / 1024 / 1024
 		records["memory_usage"] = host.Summary.QuickStats.OverallMemoryUsage
 
-
-		acc.AddFields("host", records, tags)
+		acc.AddFields(v.measurement("host"), records, tags)
 	}
 
 	return nil
 }
 
-func (v *VSphere) gatherDatastoreMetrics(acc telegraf.Accumulator, ctx context.Context, client *govmomi.Client, datastores []*object.Datastore) error {
+func (v *VSphere) gatherDatastoreMetrics(acc telegraf.Accumulator, ctx context.Context, client *govmomi.Client, datastores []*object.Datastore, dcName string) error {
 	// Convert datastores into list of references
 	var refs []types.ManagedObjectReference
+	byRef := make(map[types.ManagedObjectReference]*object.Datastore, len(datastores))
 	for _, obj := range datastores {
 		refs = append(refs, obj.Reference())
+		byRef[obj.Reference()] = obj
 	}
 
 	collector := property.DefaultCollector(client.Client)
 	var results []mo.Datastore
-	err := collector.Retrieve(ctx, refs, []string{"summary"}, &results)
+	err := collector.Retrieve(ctx, refs, []string{"summary", "customValue"}, &results)
 	if err != nil {
 		return err
 	}
@@ -201,6 +529,18 @@ func (v *VSphere) gatherDatastoreMetrics(acc telegraf.Accumulator, ctx context.C
 		tags := make(map[string]string)
 
 		tags["name"] = datastore.Summary.Name
+		tags["datacenter"] = dcName
+
+		if obj := byRef[datastore.Reference()]; obj != nil {
+			if folder := folderFromInventoryPath(obj.InventoryPath); folder != "" {
+				tags["folder"] = folder
+			}
+		}
+		for k, val := range v.entityTags(ctx, client, datastore.Reference(), datastore.CustomValue,
+			overrideOrGlobal(v.DatastoreCustomAttributes, v.CustomAttributes),
+			overrideOrGlobal(v.DatastoreTagCategories, v.TagCategories)) {
+			tags[k] = val
+		}
 
 		records["type"] = datastore.Summary.Type
 		records["health_status"] = string(datastore.OverallStatus)
@@ -209,21 +549,23 @@ func (v *VSphere) gatherDatastoreMetrics(acc telegraf.Accumulator, ctx context.C
 		records["free_space"] = datastore.Summary.FreeSpace
 		records["uncommitted_space"] = datastore.Summary.Uncommitted
 
-		acc.AddFields("datastore", records, tags)
+		acc.AddFields(v.measurement("datastore"), records, tags)
 	}
 
 	return nil
 }
 
-func (v *VSphere) gatherVMMetrics(acc telegraf.Accumulator, ctx context.Context, client *govmomi.Client, vms []*object.VirtualMachine) error {
+func (v *VSphere) gatherVMMetrics(acc telegraf.Accumulator, ctx context.Context, client *govmomi.Client, vms []*object.VirtualMachine, dcName string) error {
 	var refs []types.ManagedObjectReference
+	byRef := make(map[types.ManagedObjectReference]*object.VirtualMachine, len(vms))
 	for _, obj := range vms {
 		refs = append(refs, obj.Reference())
+		byRef[obj.Reference()] = obj
 	}
 
 	collector := property.DefaultCollector(client.Client)
 	var results []mo.VirtualMachine
-	err := collector.Retrieve(ctx, refs, []string{"name", "config", "summary"}, &results)
+	err := collector.Retrieve(ctx, refs, []string{"name", "config", "summary", "customValue"}, &results)
 	if err != nil {
 		return err
 	}
@@ -234,7 +576,19 @@ func (v *VSphere) gatherVMMetrics(acc telegraf.Accumulator, ctx context.Context,
 		tags := make(map[string]string)
 
 		tags["name"] = vm.Name
-		tags["hostname"] = vm.Summary.Guest.HostName
+		tags["hostname"] = v.shortHostname(vm.Summary.Guest.HostName)
+		tags["datacenter"] = dcName
+
+		if obj := byRef[vm.Reference()]; obj != nil {
+			if folder := folderFromInventoryPath(obj.InventoryPath); folder != "" {
+				tags["folder"] = folder
+			}
+		}
+		for k, val := range v.entityTags(ctx, client, vm.Reference(), vm.CustomValue,
+			overrideOrGlobal(v.VMCustomAttributes, v.CustomAttributes),
+			overrideOrGlobal(v.VMTagCategories, v.TagCategories)) {
+			tags[k] = val
+		}
 
 		records["guest_os_name"] = vm.Config.GuestFullName
 		records["guest_os_id"] = vm.Config.GuestId
@@ -260,7 +614,7 @@ func (v *VSphere) gatherVMMetrics(acc telegraf.Accumulator, ctx context.Context,
 		records["storage_committed"] = vm.Summary.Storage.Committed
 		records["storage_uncommitted"] = vm.Summary.Storage.Uncommitted
 
-		acc.AddFields("virtual_machine", records, tags)
+		acc.AddFields(v.measurement("virtual_machine"), records, tags)
 	}
 
 	return nil
@@ -0,0 +1,75 @@
+package vsphere
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFolderFromInventoryPath(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"empty path", "", ""},
+		{"flat, no folder", "/dc1/host/esx1.example.com", ""},
+		{"one nested folder", "/dc1/host/rack1/esx1.example.com", "rack1"},
+		{"several nested folders", "/dc1/vm/apps/billing/vm1", "apps/billing"},
+		{"no leading slash", "dc1/host/esx1.example.com", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := folderFromInventoryPath(tt.path); got != tt.want {
+				t.Errorf("folderFromInventoryPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShortHostname(t *testing.T) {
+	tests := []struct {
+		name                 string
+		removeHostDomainName bool
+		domain               string
+		hostname             string
+		want                 string
+	}{
+		{"disabled leaves name untouched", false, "", "vm1.example.com", "vm1.example.com"},
+		{"empty hostname", true, "", "", ""},
+		{"no dot in hostname", true, "", "vm1", "vm1"},
+		{"strips to first dot when domain unset", true, "", "vm1.example.com", "vm1"},
+		{"strips configured domain suffix", true, "example.com", "vm1.example.com", "vm1"},
+		{"leaves name alone when domain doesn't match", true, "other.com", "vm1.example.com", "vm1.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &VSphere{RemoveHostDomainName: tt.removeHostDomainName, Domain: tt.domain}
+			if got := v.shortHostname(tt.hostname); got != tt.want {
+				t.Errorf("shortHostname(%q) = %q, want %q", tt.hostname, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOverrideOrGlobal(t *testing.T) {
+	tests := []struct {
+		name     string
+		override []string
+		global   []string
+		want     []string
+	}{
+		{"override set wins", []string{"owner"}, []string{"env"}, []string{"owner"}},
+		{"empty override falls back to global", nil, []string{"env"}, []string{"env"}},
+		{"both empty", nil, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := overrideOrGlobal(tt.override, tt.global); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("overrideOrGlobal(%v, %v) = %v, want %v", tt.override, tt.global, got, tt.want)
+			}
+		})
+	}
+}